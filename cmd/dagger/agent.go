@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"dagger.io/go/dagger/agent"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Run a dagger agent that other dagger clients can connect to",
+	Long: `Run a long-lived dagger agent process.
+
+The agent owns a single buildkit connection and cue compiler, and accepts
+Compute jobs from any number of dagger clients whose ClientOpts.AgentAddr
+points at it, instead of each client spawning its own buildkitd
+connection.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			return err
+		}
+		host, err := cmd.Flags().GetString("buildkit-host")
+		if err != nil {
+			return err
+		}
+
+		a := agent.New(host)
+		return a.Serve(context.Background(), addr)
+	},
+}
+
+func init() {
+	agentCmd.Flags().String("listen", "127.0.0.1:8042", "address to listen for dagger clients on")
+	agentCmd.Flags().String("buildkit-host", "", "buildkit host to connect to (defaults to $BUILDKIT_HOST)")
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,344 @@
+// Package agent implements the server side of the `dagger agent` protocol
+// defined in dagger/agentrpc: a long-running process that owns a single
+// buildkit connection and cue compiler, and lets any number of dagger
+// clients submit Compute jobs to it over a WebSocket connection instead of
+// each spawning their own buildkitd client.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	bk "github.com/moby/buildkit/client"
+
+	"dagger.io/go/dagger"
+	"dagger.io/go/dagger/agentrpc"
+)
+
+// jobTTL bounds how long a finished job's buffered events and output are
+// kept around for a FetchOutput call that never comes (eg. a crashed or
+// disconnected client), so a long-running agent serving many jobs doesn't
+// grow its memory with every job it has ever run.
+const jobTTL = 10 * time.Minute
+
+var upgrader = websocket.Upgrader{
+	// The agent is expected to run behind a trusted tunnel (ssh
+	// forwarding, a private network, etc), same as buildkitd itself.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// connWriter serializes writes to a *websocket.Conn: gorilla/websocket
+// only supports one concurrent writer, but a connection fans out to many
+// goroutines here (one per inbound request, plus one per running job
+// pushing status events), so every write has to go through this.
+type connWriter struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (w *connWriter) writeJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteJSON(v)
+}
+
+// job tracks one in-flight or completed Compute call. Status events are
+// buffered in order so a StreamStatus call that lands after the job has
+// already produced (or even finished producing) events still sees all of
+// them, instead of only whatever arrives after it subscribes.
+type job struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu          sync.Mutex
+	events      []agentrpc.StatusEvent
+	subscribers []*connWriter
+
+	output json.RawMessage
+	err    error
+}
+
+// subscribe replays every event recorded so far to cw, then registers it
+// to receive future ones.
+func (j *job) subscribe(cw *connWriter) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, ev := range j.events {
+		_ = cw.writeJSON(statusNotification(ev))
+	}
+	j.subscribers = append(j.subscribers, cw)
+}
+
+// pushEvent records ev and forwards it to every subscriber registered so
+// far.
+func (j *job) pushEvent(ev agentrpc.StatusEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, ev)
+	for _, cw := range j.subscribers {
+		_ = cw.writeJSON(statusNotification(ev))
+	}
+}
+
+func statusNotification(ev agentrpc.StatusEvent) agentrpc.Request {
+	raw, err := json.Marshal(ev)
+	if err != nil {
+		raw = json.RawMessage("{}")
+	}
+	return agentrpc.Request{
+		JSONRPC: "2.0",
+		Method:  agentrpc.MethodStreamStatus,
+		Params:  raw,
+	}
+}
+
+// jobStatusWriter adapts a job's event buffer into a progress.Writer, so
+// it can be attached directly to dagger.Client.Compute.
+type jobStatusWriter struct {
+	job   *job
+	jobID string
+}
+
+func (w jobStatusWriter) Write(ctx context.Context, ch <-chan *bk.SolveStatus) error {
+	for status := range ch {
+		raw, err := json.Marshal(status)
+		if err != nil {
+			return fmt.Errorf("marshal status event: %w", err)
+		}
+		w.job.pushEvent(agentrpc.StatusEvent{JobID: w.jobID, Status: raw})
+	}
+	return nil
+}
+
+// Agent serves the agentrpc protocol over a single buildkit client shared
+// by every connected client and every job, so only one buildkitd
+// connection and cue compiler is ever spun up per agent process.
+type Agent struct {
+	buildkitHost string
+
+	clientMu sync.Mutex
+	client   *dagger.Client
+
+	jobsMu sync.Mutex
+	jobs   map[string]*job
+}
+
+// New creates an Agent that dials buildkitHost lazily, the first time a
+// job is submitted, and reuses that connection for every job after.
+func New(buildkitHost string) *Agent {
+	return &Agent{
+		buildkitHost: buildkitHost,
+		jobs:         map[string]*job{},
+	}
+}
+
+// sharedClient returns the agent's single dagger.Client, dialing it on
+// first use.
+func (a *Agent) sharedClient(ctx context.Context) (*dagger.Client, error) {
+	a.clientMu.Lock()
+	defer a.clientMu.Unlock()
+	if a.client != nil {
+		return a.client, nil
+	}
+	client, err := dagger.NewClient(ctx, a.buildkitHost, dagger.ClientOpts{})
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a.client, nil
+}
+
+// Serve listens for WebSocket connections on addr (eg. "127.0.0.1:8042")
+// and serves the agentrpc protocol on every connection until ctx is
+// cancelled.
+func (a *Agent) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/agent", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("agent: websocket upgrade failed")
+			return
+		}
+		a.serveConn(ctx, conn)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return fmt.Errorf("agent: serve: %w", err)
+	}
+	return nil
+}
+
+func (a *Agent) serveConn(ctx context.Context, conn *websocket.Conn) {
+	defer conn.Close()
+	cw := &connWriter{conn: conn}
+	for {
+		var req agentrpc.Request
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		go a.handle(ctx, cw, &req)
+	}
+}
+
+func (a *Agent) handle(ctx context.Context, cw *connWriter, req *agentrpc.Request) {
+	var (
+		result interface{}
+		rpcErr *agentrpc.Error
+	)
+
+	switch req.Method {
+	case agentrpc.MethodCompute:
+		var params agentrpc.ComputeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rpcErr = &agentrpc.Error{Code: 400, Message: err.Error()}
+			break
+		}
+		jobID := uuid.New().String()
+		a.startJob(ctx, jobID, params.EnvPlan)
+		result = agentrpc.ComputeResult{JobID: jobID}
+
+	case agentrpc.MethodCancel:
+		var params agentrpc.CancelParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rpcErr = &agentrpc.Error{Code: 400, Message: err.Error()}
+			break
+		}
+		a.jobsMu.Lock()
+		j, ok := a.jobs[params.JobID]
+		a.jobsMu.Unlock()
+		if ok {
+			j.cancel()
+		}
+
+	case agentrpc.MethodStreamStatus:
+		var params agentrpc.StreamStatusParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rpcErr = &agentrpc.Error{Code: 400, Message: err.Error()}
+			break
+		}
+		a.jobsMu.Lock()
+		j, ok := a.jobs[params.JobID]
+		a.jobsMu.Unlock()
+		if !ok {
+			rpcErr = &agentrpc.Error{Code: 404, Message: "unknown job"}
+			break
+		}
+		j.subscribe(cw)
+
+	case agentrpc.MethodFetchOutput:
+		var params agentrpc.FetchOutputParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rpcErr = &agentrpc.Error{Code: 400, Message: err.Error()}
+			break
+		}
+		a.jobsMu.Lock()
+		j, ok := a.jobs[params.JobID]
+		a.jobsMu.Unlock()
+		if !ok {
+			rpcErr = &agentrpc.Error{Code: 404, Message: "unknown job"}
+			break
+		}
+		<-j.done
+		a.evictJob(params.JobID)
+		if j.err != nil {
+			rpcErr = &agentrpc.Error{Code: 500, Message: j.err.Error()}
+			break
+		}
+		result = agentrpc.FetchOutputResult{Output: j.output}
+
+	default:
+		rpcErr = &agentrpc.Error{Code: 404, Message: "unknown method: " + req.Method}
+	}
+
+	if req.ID == nil {
+		return
+	}
+	resp := agentrpc.Response{JSONRPC: "2.0", ID: *req.ID, Error: rpcErr}
+	if rpcErr == nil && result != nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &agentrpc.Error{Code: 500, Message: err.Error()}
+		} else {
+			resp.Result = raw
+		}
+	}
+	_ = cw.writeJSON(resp)
+}
+
+// startJob runs env on the agent's shared dagger.Client, recording every
+// SolveStatus event it produces (so a StreamStatus subscriber can never
+// miss one, however late it subscribes) and the final output/error for a
+// later FetchOutput call.
+func (a *Agent) startJob(ctx context.Context, jobID string, envPlan json.RawMessage) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	j := &job{cancel: cancel, done: make(chan struct{})}
+	a.jobsMu.Lock()
+	a.jobs[jobID] = j
+	a.jobsMu.Unlock()
+
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		defer func() { j.pushDone(jobID) }()
+		defer time.AfterFunc(jobTTL, func() { a.evictJob(jobID) })
+
+		env, err := dagger.DecodeEnv(envPlan)
+		if err != nil {
+			j.err = fmt.Errorf("decode env plan: %w", err)
+			return
+		}
+
+		client, err := a.sharedClient(jobCtx)
+		if err != nil {
+			j.err = err
+			return
+		}
+
+		out, err := client.Compute(jobCtx, env, jobStatusWriter{job: j, jobID: jobID})
+		if err != nil {
+			j.err = err
+			return
+		}
+
+		outJSON, err := json.Marshal(out)
+		if err != nil {
+			j.err = fmt.Errorf("marshal output: %w", err)
+			return
+		}
+		j.output = outJSON
+	}()
+}
+
+// evictJob removes jobID from the agent's job table, if still present, so
+// its buffered events, subscribers and output become eligible for GC.
+// Called once a FetchOutput has consumed the job's result, and also on a
+// jobTTL timer so a job whose result is never fetched doesn't linger
+// forever.
+func (a *Agent) evictJob(jobID string) {
+	a.jobsMu.Lock()
+	delete(a.jobs, jobID)
+	a.jobsMu.Unlock()
+}
+
+// pushDone emits the terminal StatusEvent that tells computeViaAgent (on
+// the client side) to stop reading the status stream.
+func (j *job) pushDone(jobID string) {
+	ev := agentrpc.StatusEvent{JobID: jobID, Status: json.RawMessage("null"), Done: true}
+	if j.err != nil {
+		ev.Err = j.err.Error()
+	}
+	j.pushEvent(ev)
+}
@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"dagger.io/go/dagger/agentrpc"
+)
+
+// newTestConnPair spins up a throwaway HTTP server that upgrades a single
+// incoming connection to a websocket, and returns both ends: the
+// server-side *websocket.Conn (for wrapping in a connWriter, same as
+// Agent.serveConn does) and the client-side one (for reading what the
+// server writes, same as agentrpc.Client would).
+func newTestConnPair(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	accepted := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		accepted <- c
+	}))
+	t.Cleanup(srv.Close)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	server = <-accepted
+	t.Cleanup(func() { server.Close() })
+	return server, client
+}
+
+func readStatusEvent(t *testing.T, conn *websocket.Conn) agentrpc.StatusEvent {
+	t.Helper()
+	var req agentrpc.Request
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Fatalf("read notification: %v", err)
+	}
+	var ev agentrpc.StatusEvent
+	if err := json.Unmarshal(req.Params, &ev); err != nil {
+		t.Fatalf("decode status event: %v", err)
+	}
+	return ev
+}
+
+func TestJobSubscribeBeforeAndAfterEvents(t *testing.T) {
+	j := &job{done: make(chan struct{})}
+
+	// Subscribing before any events are pushed should see nothing
+	// replayed, only the event pushed afterwards.
+	earlyServer, earlyClient := newTestConnPair(t)
+	j.subscribe(&connWriter{conn: earlyServer})
+
+	j.pushEvent(agentrpc.StatusEvent{JobID: "job-1", Status: json.RawMessage(`{"n":1}`)})
+
+	if ev := readStatusEvent(t, earlyClient); string(ev.Status) != `{"n":1}` {
+		t.Errorf("early subscriber status = %s, want {\"n\":1}", ev.Status)
+	}
+
+	// Subscribing after the event was already buffered should replay it.
+	lateServer, lateClient := newTestConnPair(t)
+	j.subscribe(&connWriter{conn: lateServer})
+
+	if ev := readStatusEvent(t, lateClient); string(ev.Status) != `{"n":1}` {
+		t.Errorf("late subscriber replayed status = %s, want {\"n\":1}", ev.Status)
+	}
+
+	// Both subscribers should still receive events pushed after they
+	// joined.
+	j.pushEvent(agentrpc.StatusEvent{JobID: "job-1", Status: json.RawMessage(`{"n":2}`)})
+	if ev := readStatusEvent(t, earlyClient); string(ev.Status) != `{"n":2}` {
+		t.Errorf("early subscriber status = %s, want {\"n\":2}", ev.Status)
+	}
+	if ev := readStatusEvent(t, lateClient); string(ev.Status) != `{"n":2}` {
+		t.Errorf("late subscriber status = %s, want {\"n\":2}", ev.Status)
+	}
+}
+
+func TestJobConcurrentStreamStatusSubscribers(t *testing.T) {
+	j := &job{done: make(chan struct{})}
+
+	const n = 8
+	clients := make([]*websocket.Conn, n)
+	writers := make([]*connWriter, n)
+	for i := 0; i < n; i++ {
+		serverConn, clientConn := newTestConnPair(t)
+		clients[i] = clientConn
+		writers[i] = &connWriter{conn: serverConn}
+	}
+
+	var wg sync.WaitGroup
+	for _, cw := range writers {
+		wg.Add(1)
+		go func(cw *connWriter) {
+			defer wg.Done()
+			j.subscribe(cw)
+		}(cw)
+	}
+	wg.Wait()
+
+	j.pushEvent(agentrpc.StatusEvent{JobID: "job-2", Status: json.RawMessage(`{"n":1}`)})
+
+	for i, conn := range clients {
+		if ev := readStatusEvent(t, conn); ev.JobID != "job-2" {
+			t.Errorf("subscriber %d: JobID = %q, want job-2", i, ev.JobID)
+		}
+	}
+}
+
+func TestAgentCancelCallsJobCancel(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+
+	var mu sync.Mutex
+	var cancelled bool
+	j := &job{
+		done: done,
+		cancel: func() {
+			mu.Lock()
+			cancelled = true
+			mu.Unlock()
+		},
+	}
+
+	a := &Agent{jobs: map[string]*job{"job-3": j}}
+	serverConn, _ := newTestConnPair(t)
+	cw := &connWriter{conn: serverConn}
+
+	req := &agentrpc.Request{
+		JSONRPC: "2.0",
+		Method:  agentrpc.MethodCancel,
+		Params:  mustMarshal(t, agentrpc.CancelParams{JobID: "job-3"}),
+	}
+	a.handle(context.Background(), cw, req)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !cancelled {
+		t.Errorf("expected job.cancel to be called")
+	}
+}
+
+func TestAgentFetchOutputEvictsJob(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	j := &job{done: done, output: json.RawMessage(`{"ok":true}`)}
+
+	a := &Agent{jobs: map[string]*job{"job-4": j}}
+	serverConn, _ := newTestConnPair(t)
+	cw := &connWriter{conn: serverConn}
+
+	id := uint64(1)
+	req := &agentrpc.Request{
+		JSONRPC: "2.0",
+		ID:      &id,
+		Method:  agentrpc.MethodFetchOutput,
+		Params:  mustMarshal(t, agentrpc.FetchOutputParams{JobID: "job-4"}),
+	}
+	a.handle(context.Background(), cw, req)
+
+	a.jobsMu.Lock()
+	_, stillPresent := a.jobs["job-4"]
+	a.jobsMu.Unlock()
+	if stillPresent {
+		t.Errorf("expected job to be evicted from Agent.jobs after FetchOutput")
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return raw
+}
@@ -0,0 +1,188 @@
+package agentrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client is a thin RPC client for talking to a `dagger agent` process. A
+// single Client multiplexes any number of concurrent jobs over one
+// WebSocket connection.
+type Client struct {
+	conn *websocket.Conn
+
+	nextID uint64
+
+	mu       sync.Mutex
+	pending  map[uint64]chan *Response
+	watchers map[string][]chan *StatusEvent
+}
+
+// Dial connects to a dagger agent listening at addr (eg.
+// "ws://127.0.0.1:8042/agent").
+func Dial(ctx context.Context, addr string) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, addr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial agent: %w", err)
+	}
+	c := &Client{
+		conn:     conn,
+		pending:  map[uint64]chan *Response{},
+		watchers: map[string][]chan *StatusEvent{},
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) readLoop() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.broadcastClosed()
+			return
+		}
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			continue
+		}
+
+		if req.ID == nil {
+			c.handleNotification(&req)
+			continue
+		}
+
+		var resp Response
+		if err := json.Unmarshal(data, &resp); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- &resp
+		}
+	}
+}
+
+func (c *Client) handleNotification(req *Request) {
+	if req.Method != MethodStreamStatus {
+		return
+	}
+	var ev StatusEvent
+	if err := json.Unmarshal(req.Params, &ev); err != nil {
+		return
+	}
+	c.mu.Lock()
+	watchers := c.watchers[ev.JobID]
+	if ev.Done {
+		// This is the last notification the agent will ever send for
+		// this job: drop the watcher list so it doesn't sit in the map
+		// for the remaining life of the connection.
+		delete(c.watchers, ev.JobID)
+	}
+	c.mu.Unlock()
+	for _, w := range watchers {
+		w <- &ev
+		if ev.Done {
+			close(w)
+		}
+	}
+}
+
+func (c *Client) broadcastClosed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	for _, watchers := range c.watchers {
+		for _, w := range watchers {
+			close(w)
+		}
+	}
+}
+
+func (c *Client) call(method string, params interface{}, result interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal params: %w", err)
+	}
+
+	id := atomic.AddUint64(&c.nextID, 1)
+	req := Request{
+		JSONRPC: "2.0",
+		ID:      &id,
+		Method:  method,
+		Params:  raw,
+	}
+
+	ch := make(chan *Response, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.conn.WriteJSON(req); err != nil {
+		return fmt.Errorf("write request: %w", err)
+	}
+
+	resp, ok := <-ch
+	if !ok {
+		return fmt.Errorf("agent connection closed while waiting for %s", method)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// Compute starts a new job on the agent and returns its job ID.
+func (c *Client) Compute(envPlan json.RawMessage) (string, error) {
+	var result ComputeResult
+	if err := c.call(MethodCompute, ComputeParams{EnvPlan: envPlan}, &result); err != nil {
+		return "", err
+	}
+	return result.JobID, nil
+}
+
+// Cancel stops a running job.
+func (c *Client) Cancel(jobID string) error {
+	return c.call(MethodCancel, CancelParams{JobID: jobID}, nil)
+}
+
+// StreamStatus subscribes to SolveStatus events for jobID. The returned
+// channel is closed once the agent reports the job done (or the
+// connection drops).
+func (c *Client) StreamStatus(jobID string) (<-chan *StatusEvent, error) {
+	ch := make(chan *StatusEvent, 16)
+	c.mu.Lock()
+	c.watchers[jobID] = append(c.watchers[jobID], ch)
+	c.mu.Unlock()
+
+	if err := c.call(MethodStreamStatus, StreamStatusParams{JobID: jobID}, nil); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// FetchOutput retrieves the compiled cue output of a finished job.
+func (c *Client) FetchOutput(jobID string) (json.RawMessage, error) {
+	var result FetchOutputResult
+	if err := c.call(MethodFetchOutput, FetchOutputParams{JobID: jobID}, &result); err != nil {
+		return nil, err
+	}
+	return result.Output, nil
+}
@@ -0,0 +1,113 @@
+package agentrpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newTestClient() *Client {
+	return &Client{
+		pending:  map[uint64]chan *Response{},
+		watchers: map[string][]chan *StatusEvent{},
+	}
+}
+
+func marshalRequest(t *testing.T, method string, params interface{}) *Request {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	return &Request{JSONRPC: "2.0", Method: method, Params: raw}
+}
+
+func TestHandleNotificationDeliversToWatcher(t *testing.T) {
+	c := newTestClient()
+	ch := make(chan *StatusEvent, 1)
+	c.watchers["job-1"] = []chan *StatusEvent{ch}
+
+	c.handleNotification(marshalRequest(t, MethodStreamStatus, StatusEvent{
+		JobID:  "job-1",
+		Status: json.RawMessage(`{"n":1}`),
+	}))
+
+	select {
+	case ev := <-ch:
+		if string(ev.Status) != `{"n":1}` {
+			t.Errorf("Status = %s, want {\"n\":1}", ev.Status)
+		}
+	default:
+		t.Fatal("expected an event to be delivered")
+	}
+
+	if _, ok := c.watchers["job-1"]; !ok {
+		t.Errorf("watcher should remain registered for a non-terminal event")
+	}
+}
+
+func TestHandleNotificationEvictsWatchersOnDone(t *testing.T) {
+	c := newTestClient()
+	ch := make(chan *StatusEvent, 1)
+	c.watchers["job-2"] = []chan *StatusEvent{ch}
+
+	c.handleNotification(marshalRequest(t, MethodStreamStatus, StatusEvent{
+		JobID: "job-2",
+		Done:  true,
+	}))
+
+	ev, ok := <-ch
+	if !ok || ev == nil {
+		t.Fatalf("expected the done event to be delivered before closing")
+	}
+	if _, ok := <-ch; ok {
+		t.Errorf("expected channel to be closed after a done event")
+	}
+
+	c.mu.Lock()
+	_, stillPresent := c.watchers["job-2"]
+	c.mu.Unlock()
+	if stillPresent {
+		t.Errorf("expected watchers entry to be evicted once the job is done")
+	}
+}
+
+func TestHandleNotificationFansOutToAllWatchers(t *testing.T) {
+	c := newTestClient()
+	const n = 4
+	chans := make([]chan *StatusEvent, n)
+	for i := range chans {
+		chans[i] = make(chan *StatusEvent, 1)
+		c.watchers["job-3"] = append(c.watchers["job-3"], chans[i])
+	}
+
+	c.handleNotification(marshalRequest(t, MethodStreamStatus, StatusEvent{
+		JobID: "job-3",
+		Done:  true,
+	}))
+
+	for i, ch := range chans {
+		if _, ok := <-ch; !ok {
+			t.Errorf("watcher %d: expected to receive the done event", i)
+		}
+		if _, ok := <-ch; ok {
+			t.Errorf("watcher %d: expected channel to be closed", i)
+		}
+	}
+}
+
+func TestBroadcastClosedClosesPendingAndWatchers(t *testing.T) {
+	c := newTestClient()
+	pending := make(chan *Response)
+	c.pending[1] = pending
+	watcher := make(chan *StatusEvent)
+	c.watchers["job-4"] = []chan *StatusEvent{watcher}
+
+	c.broadcastClosed()
+
+	if _, ok := <-pending; ok {
+		t.Errorf("expected pending channel to be closed")
+	}
+	if _, ok := <-watcher; ok {
+		t.Errorf("expected watcher channel to be closed")
+	}
+}
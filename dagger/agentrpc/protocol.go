@@ -0,0 +1,98 @@
+// Package agentrpc defines the wire protocol spoken between a dagger
+// client and a `dagger agent` process. It is a thin JSON-RPC 2.0 protocol
+// carried over a single WebSocket connection: requests and notifications
+// are multiplexed on one socket so a long-running agent can stream
+// SolveStatus events back to the client while a Compute call is in flight.
+package agentrpc
+
+import "encoding/json"
+
+// ProtocolVersion is bumped whenever a breaking change is made to the
+// request/response shapes below. It is not sent on the wire: Client and
+// the agent neither exchange nor check it.
+const ProtocolVersion = 1
+
+// Method names for the agent's JSON-RPC methods.
+const (
+	MethodCompute      = "dagger/compute"
+	MethodCancel       = "dagger/cancel"
+	MethodStreamStatus = "dagger/streamStatus"
+	MethodFetchOutput  = "dagger/fetchOutput"
+)
+
+// Request is a JSON-RPC 2.0 request or notification (Id == nil).
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *uint64         `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// ComputeParams starts a new compute job on the agent.
+type ComputeParams struct {
+	// JobID is not read by the agent: it assigns every job its own ID,
+	// returned in ComputeResult, for subsequent Cancel/StreamStatus/
+	// FetchOutput calls.
+	JobID string `json:"jobId"`
+	// EnvPlan is the serialized dagger.Env to compute.
+	EnvPlan json.RawMessage `json:"envPlan"`
+}
+
+// ComputeResult acknowledges that a compute job has been accepted. The
+// actual cue output is retrieved separately via FetchOutput once the
+// status stream reports completion.
+type ComputeResult struct {
+	JobID string `json:"jobId"`
+}
+
+// CancelParams stops a running job.
+type CancelParams struct {
+	JobID string `json:"jobId"`
+}
+
+// StreamStatusParams subscribes the connection to SolveStatus
+// notifications for a job. Status events are delivered as
+// MethodStreamStatus notifications (ID == nil) carrying a StatusEvent.
+type StreamStatusParams struct {
+	JobID string `json:"jobId"`
+}
+
+// StatusEvent wraps a single buildkit SolveStatus, JSON-encoded by the
+// caller (dagger depends on buildkit's own (de)serialization for the
+// SolveStatus type itself).
+type StatusEvent struct {
+	JobID  string          `json:"jobId"`
+	Status json.RawMessage `json:"status"`
+	// Done is set on the final event for a job, after which no more
+	// StreamStatus notifications will be sent for it.
+	Done bool `json:"done"`
+	// Err is set alongside Done if the job failed.
+	Err string `json:"err,omitempty"`
+}
+
+// FetchOutputParams retrieves the compiled cue output of a finished job.
+type FetchOutputParams struct {
+	JobID string `json:"jobId"`
+}
+
+// FetchOutputResult carries the compiled cue output, serialized the same
+// way compiler.Value is marshaled elsewhere in dagger.
+type FetchOutputResult struct {
+	Output json.RawMessage `json:"output"`
+}
@@ -0,0 +1,94 @@
+package dagger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ClientBackend selects how NewClient reaches a buildkit daemon.
+type ClientBackend string
+
+const (
+	// BackendAuto detects the backend to use based on the environment:
+	// a rootless user socket when running as non-root, a containerd
+	// socket when one is present, and docker-container://buildkitd
+	// otherwise.
+	BackendAuto ClientBackend = ""
+	// BackendDockerContainer dials buildkitd running in the
+	// "buildkitd" docker container, exactly like the previous
+	// hardcoded default.
+	BackendDockerContainer ClientBackend = "docker-container"
+	// BackendRootless dials a rootless buildkitd over its per-user
+	// socket under $XDG_RUNTIME_DIR.
+	BackendRootless ClientBackend = "rootless"
+	// BackendContainerd dials a buildkitd reachable via a containerd
+	// socket, using the containerd connhelper.
+	BackendContainerd ClientBackend = "containerd"
+)
+
+const defaultContainerdSocket = "/run/containerd/containerd.sock"
+
+// rootlessSocketPath returns the unix socket a rootless buildkitd listens
+// on by convention, under $XDG_RUNTIME_DIR (falling back to
+// /run/user/$UID, same as buildkitd itself does).
+func rootlessSocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(dir, "buildkit", "buildkitd.sock")
+}
+
+// detectBackend picks a backend when the caller didn't ask for one
+// explicitly: prefer a rootless socket when not running as root, then a
+// containerd socket, falling back to the original docker-container
+// default.
+func detectBackend() ClientBackend {
+	if os.Geteuid() != 0 {
+		if _, err := os.Stat(rootlessSocketPath()); err == nil {
+			return BackendRootless
+		}
+	}
+	if _, err := os.Stat(defaultContainerdSocket); err == nil {
+		return BackendContainerd
+	}
+	return BackendDockerContainer
+}
+
+// resolveHost turns (host, backend) into the buildkit host string bk.New
+// expects. An explicit host (or $BUILDKIT_HOST) always wins; otherwise the
+// backend (autodetected if BackendAuto) picks a sensible default.
+func resolveHost(host string, backend ClientBackend) (string, error) {
+	if host != "" {
+		return host, nil
+	}
+	if host = os.Getenv("BUILDKIT_HOST"); host != "" {
+		return host, nil
+	}
+
+	if backend == BackendAuto {
+		backend = detectBackend()
+	}
+	switch backend {
+	case BackendRootless:
+		return "unix://" + rootlessSocketPath(), nil
+	case BackendContainerd:
+		return "containerd://" + defaultContainerdSocket, nil
+	case BackendDockerContainer:
+		return defaultBuildkitHost, nil
+	default:
+		return "", fmt.Errorf("unknown client backend %q", backend)
+	}
+}
+
+// NewEmbeddedClient is not yet implemented: running an in-process buildkit
+// worker (OCI/runc executor, no external buildkitd required) needs its
+// own root/state dir management that doesn't have an obvious home in this
+// package yet. There is deliberately no BackendEmbedded ClientBackend
+// value until this lands, so NewClient can't be pointed at a backend
+// that's guaranteed to fail at runtime.
+func NewEmbeddedClient(ctx context.Context, opts ClientOpts) (*Client, error) {
+	return nil, fmt.Errorf("embedded buildkit worker not yet implemented")
+}
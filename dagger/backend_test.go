@@ -0,0 +1,66 @@
+package dagger
+
+import "testing"
+
+func TestResolveHost(t *testing.T) {
+	t.Setenv("BUILDKIT_HOST", "")
+
+	cases := []struct {
+		name    string
+		host    string
+		backend ClientBackend
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "explicit host always wins",
+			host: "tcp://buildkitd.example.com:1234",
+			want: "tcp://buildkitd.example.com:1234",
+		},
+		{
+			name:    "docker-container backend",
+			backend: BackendDockerContainer,
+			want:    defaultBuildkitHost,
+		},
+		{
+			name:    "rootless backend",
+			backend: BackendRootless,
+			want:    "unix://" + rootlessSocketPath(),
+		},
+		{
+			name:    "containerd backend",
+			backend: BackendContainerd,
+			want:    "containerd://" + defaultContainerdSocket,
+		},
+		{
+			name:    "unknown backend is an error",
+			backend: ClientBackend("bogus"),
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := resolveHost(c.host, c.backend)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("resolveHost(%q, %q) = %q, want %q", c.host, c.backend, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRootlessSocketPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got, want := rootlessSocketPath(), "/run/user/1000/buildkit/buildkitd.sock"; got != want {
+		t.Errorf("rootlessSocketPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package dagger
+
+import (
+	"fmt"
+	"strings"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+// cacheImportOpts converts the ref strings configured on ClientOpts into
+// buildkit cache import entries. Each ref is either a full attribute list
+// ("type=registry,ref=foo/bar:cache"), a "type=local,src=..." / GHA-style
+// ref, or a bare image ref, which is shorthand for "type=registry,ref=...".
+func cacheImportOpts(refs []string) ([]bk.CacheOptionsEntry, error) {
+	var entries []bk.CacheOptionsEntry
+	for _, ref := range refs {
+		entry, err := parseCacheOptionsEntry(ref, "registry")
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", ref, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// cacheExportOpts converts the export-related ClientOpts fields into the
+// buildkit cache export entries requested. CacheExportRef and
+// CacheExportInline are independent: a ref alone exports to a
+// registry/local/gha-style backend, inline alone embeds the cache in the
+// exported image manifest, and both together produce one entry of each
+// kind.
+func cacheExportOpts(opts ClientOpts) ([]bk.CacheOptionsEntry, error) {
+	if opts.CacheExportRef == "" && !opts.CacheExportInline {
+		return nil, nil
+	}
+
+	mode := opts.CacheExportMode
+	if mode == "" {
+		mode = cacheExportModeMin
+	}
+	if mode != cacheExportModeMin && mode != cacheExportModeMax {
+		return nil, fmt.Errorf("invalid cache export mode %q (must be %q or %q)", mode, cacheExportModeMin, cacheExportModeMax)
+	}
+
+	var entries []bk.CacheOptionsEntry
+	if opts.CacheExportRef != "" {
+		entry, err := parseCacheOptionsEntry(opts.CacheExportRef, "registry")
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", opts.CacheExportRef, err)
+		}
+		entry.Attrs["mode"] = mode
+		entries = append(entries, entry)
+	}
+	if opts.CacheExportInline {
+		entries = append(entries, bk.CacheOptionsEntry{
+			Type:  "inline",
+			Attrs: map[string]string{"mode": mode},
+		})
+	}
+
+	return entries, nil
+}
+
+// parseCacheOptionsEntry parses a buildctl-style comma-separated attribute
+// string (eg. "type=registry,ref=foo/bar:cache,oci-mediatypes=true") into a
+// bk.CacheOptionsEntry. A ref with no "type=" attribute is treated as a bare
+// reference using defaultType (eg. a plain registry image ref).
+func parseCacheOptionsEntry(s string, defaultType string) (bk.CacheOptionsEntry, error) {
+	attrs := map[string]string{}
+	if !strings.Contains(s, "=") {
+		return bk.CacheOptionsEntry{
+			Type:  defaultType,
+			Attrs: map[string]string{"ref": s},
+		}, nil
+	}
+
+	typ := defaultType
+	for _, field := range strings.Split(s, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return bk.CacheOptionsEntry{}, fmt.Errorf("invalid attribute %q", field)
+		}
+		key, value := kv[0], kv[1]
+		if key == "type" {
+			typ = value
+			continue
+		}
+		attrs[key] = value
+	}
+
+	return bk.CacheOptionsEntry{
+		Type:  typ,
+		Attrs: attrs,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package dagger
+
+import (
+	"testing"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+func TestParseCacheOptionsEntry(t *testing.T) {
+	cases := []struct {
+		name        string
+		in          string
+		defaultType string
+		want        bk.CacheOptionsEntry
+		wantErr     bool
+	}{
+		{
+			name:        "bare ref uses default type",
+			in:          "registry.example.com/foo/bar:cache",
+			defaultType: "registry",
+			want: bk.CacheOptionsEntry{
+				Type:  "registry",
+				Attrs: map[string]string{"ref": "registry.example.com/foo/bar:cache"},
+			},
+		},
+		{
+			name:        "explicit type overrides default",
+			in:          "type=local,src=/tmp/cache",
+			defaultType: "registry",
+			want: bk.CacheOptionsEntry{
+				Type:  "local",
+				Attrs: map[string]string{"src": "/tmp/cache"},
+			},
+		},
+		{
+			name:        "multiple attrs, type in the middle",
+			in:          "ref=foo/bar:cache,type=registry,oci-mediatypes=true",
+			defaultType: "registry",
+			want: bk.CacheOptionsEntry{
+				Type: "registry",
+				Attrs: map[string]string{
+					"ref":            "foo/bar:cache",
+					"oci-mediatypes": "true",
+				},
+			},
+		},
+		{
+			name:        "malformed attribute",
+			in:          "type=registry,noequals",
+			defaultType: "registry",
+			wantErr:     true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseCacheOptionsEntry(c.in, c.defaultType)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Type != c.want.Type {
+				t.Errorf("Type = %q, want %q", got.Type, c.want.Type)
+			}
+			if len(got.Attrs) != len(c.want.Attrs) {
+				t.Errorf("Attrs = %v, want %v", got.Attrs, c.want.Attrs)
+			}
+			for k, v := range c.want.Attrs {
+				if got.Attrs[k] != v {
+					t.Errorf("Attrs[%q] = %q, want %q", k, got.Attrs[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheExportOpts(t *testing.T) {
+	cases := []struct {
+		name      string
+		opts      ClientOpts
+		wantTypes []string
+		wantErr   bool
+	}{
+		{
+			name:      "nothing configured",
+			opts:      ClientOpts{},
+			wantTypes: nil,
+		},
+		{
+			name:      "ref only",
+			opts:      ClientOpts{CacheExportRef: "foo/bar:cache"},
+			wantTypes: []string{"registry"},
+		},
+		{
+			name:      "inline only, no ref",
+			opts:      ClientOpts{CacheExportInline: true},
+			wantTypes: []string{"inline"},
+		},
+		{
+			name:      "ref and inline together produce one entry of each",
+			opts:      ClientOpts{CacheExportRef: "foo/bar:cache", CacheExportInline: true},
+			wantTypes: []string{"registry", "inline"},
+		},
+		{
+			name:    "invalid mode",
+			opts:    ClientOpts{CacheExportRef: "foo/bar:cache", CacheExportMode: "bogus"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries, err := cacheExportOpts(c.opts)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != len(c.wantTypes) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(c.wantTypes))
+			}
+			for i, wantType := range c.wantTypes {
+				if entries[i].Type != wantType {
+					t.Errorf("entries[%d].Type = %q, want %q", i, entries[i].Type, wantType)
+				}
+				if entries[i].Attrs["mode"] == "" {
+					t.Errorf("entries[%d]: expected mode attr to be set", i)
+				}
+			}
+			if len(c.wantTypes) == 2 && entries[0].Attrs["ref"] != "foo/bar:cache" {
+				t.Errorf("registry entry missing ref attr: %v", entries[0].Attrs)
+			}
+		})
+	}
+}
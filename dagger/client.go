@@ -2,11 +2,12 @@ package dagger
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -21,39 +22,115 @@ import (
 	_ "github.com/moby/buildkit/client/connhelper/dockercontainer" // import the container connection driver
 	bkgw "github.com/moby/buildkit/frontend/gateway/client"
 
-	// docker output
-	"dagger.io/go/pkg/progressui"
-
+	"dagger.io/go/dagger/agentrpc"
 	"dagger.io/go/dagger/compiler"
+	_ "dagger.io/go/dagger/connhelper/containerd" // import the containerd connection driver
+	"dagger.io/go/dagger/progress"
 )
 
 const (
 	defaultBuildkitHost = "docker-container://buildkitd"
+
+	cacheExportModeMin = "min"
+	cacheExportModeMax = "max"
 )
 
+// ClientOpts holds optional settings for NewClient, covering things that
+// apply to every Compute() call made through the resulting Client (as
+// opposed to settings that belong on the Env being computed).
+type ClientOpts struct {
+	// CacheImportRefs are buildkit cache import sources, eg.
+	// "type=registry,ref=registry.example.com/foo/bar:cache" or a bare
+	// image ref (treated as "type=registry,ref=...").
+	CacheImportRefs []string
+
+	// CacheExportRef is the buildkit cache export destination, using the
+	// same ref conventions as CacheImportRefs.
+	CacheExportRef string
+	// CacheExportMode selects how much cache is exported: "min" (default)
+	// exports only the layers required to reproduce the final result,
+	// "max" exports every intermediate layer.
+	CacheExportMode string
+	// CacheExportInline embeds the cache into the exported image manifest
+	// instead of (or in addition to) pushing to CacheExportRef.
+	CacheExportInline bool
+
+	// AgentAddr, if set, points the client at a long-running `dagger
+	// agent` process instead of dialing buildkitd directly. The agent
+	// owns the buildkit connection and cue compiler, so many clients can
+	// share one warm process.
+	AgentAddr string
+
+	// Outputs are additional artifacts to export alongside the internal
+	// cue-output tar stream, eg. an OCI image or a local directory.
+	//
+	// FIXME: today these have to be set up front on the client; the goal
+	// is for cue configs to declare them inline via an env.Outputs() API
+	// (#Image, #LocalDir, ...) so they don't need to be known before
+	// Compute() runs.
+	Outputs []Output
+
+	// Secrets are made available to cue configs (via the #Secret stdlib
+	// op) without ever touching a local dir that buildkit would cache.
+	Secrets []SecretSource
+	// SSHSockets are SSH agent sockets made available to cue configs via
+	// the #SSHSocket stdlib op.
+	SSHSockets []SSHSocket
+
+	// Network overrides the DNS/hosts configuration seen by every
+	// container the solver executes. Left zero-valued, buildkit's own
+	// defaults apply.
+	Network NetworkConfig
+
+	// Backend selects how NewClient reaches buildkit when host is empty
+	// and $BUILDKIT_HOST is unset.
+	// Defaults to BackendAuto.
+	Backend ClientBackend
+}
+
 // A dagger client
 type Client struct {
-	c *bk.Client
+	c     *bk.Client
+	agent *agentrpc.Client
+	opts  ClientOpts
 }
 
-func NewClient(ctx context.Context, host string) (*Client, error) {
-	if host == "" {
-		host = os.Getenv("BUILDKIT_HOST")
+func NewClient(ctx context.Context, host string, opts ClientOpts) (*Client, error) {
+	if opts.AgentAddr != "" {
+		agent, err := agentrpc.Dial(ctx, opts.AgentAddr)
+		if err != nil {
+			return nil, fmt.Errorf("dial dagger agent: %w", err)
+		}
+		return &Client{
+			agent: agent,
+			opts:  opts,
+		}, nil
 	}
-	if host == "" {
-		host = defaultBuildkitHost
+
+	host, err := resolveHost(host, opts.Backend)
+	if err != nil {
+		return nil, err
 	}
 	c, err := bk.New(ctx, host)
 	if err != nil {
 		return nil, fmt.Errorf("buildkit client: %w", err)
 	}
 	return &Client{
-		c: c,
+		c:    c,
+		opts: opts,
 	}, nil
 }
 
 // FIXME: return completed *Env, instead of *compiler.Value
-func (c *Client) Compute(ctx context.Context, env *Env) (*compiler.Value, error) {
+//
+// writers are additional progress.Writer sinks for the solve status
+// stream, on top of the default zerolog output (eg. progress.TTY,
+// progress.JSONL, or a progress.Recorder for later replay).
+func (c *Client) Compute(ctx context.Context, env *Env, writers ...progress.Writer) (*compiler.Value, error) {
+	if c.agent != nil {
+		return c.computeViaAgent(ctx, env, writers...)
+	}
+
 	lg := log.Ctx(ctx)
 	eg, gctx := errgroup.WithContext(ctx)
 
@@ -63,7 +140,7 @@ func (c *Client) Compute(ctx context.Context, env *Env) (*compiler.Value, error)
 		// Create a background context so that logging will not be cancelled
 		// with the main context.
 		dispCtx := lg.WithContext(context.Background())
-		return c.logSolveStatus(dispCtx, events)
+		return progress.Fanout(dispCtx, events, append([]progress.Writer{progress.Zerolog{}}, writers...)...)
 	})
 
 	// Spawn build function
@@ -87,6 +164,69 @@ func (c *Client) Compute(ctx context.Context, env *Env) (*compiler.Value, error)
 	return out, compiler.Err(eg.Wait())
 }
 
+// computeViaAgent runs env through a remote `dagger agent` instead of a
+// local buildkitd: it ships the env plan over the RPC connection, relays
+// the agent's SolveStatus stream to the same progress writers a local
+// Compute would use, then fetches and compiles the resulting cue output.
+func (c *Client) computeViaAgent(ctx context.Context, env *Env, writers ...progress.Writer) (*compiler.Value, error) {
+	lg := log.Ctx(ctx)
+
+	envPlan, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("marshal env: %w", err)
+	}
+
+	jobID, err := c.agent.Compute(envPlan)
+	if err != nil {
+		return nil, fmt.Errorf("agent compute: %w", err)
+	}
+
+	statusCh, err := c.agent.StreamStatus(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("agent stream status: %w", err)
+	}
+
+	events := make(chan *bk.SolveStatus)
+	eg, gctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		dispCtx := lg.WithContext(context.Background())
+		return progress.Fanout(dispCtx, events, append([]progress.Writer{progress.Zerolog{}}, writers...)...)
+	})
+	eg.Go(func() error {
+		defer close(events)
+		for {
+			select {
+			case <-gctx.Done():
+				return gctx.Err()
+			case ev, ok := <-statusCh:
+				if !ok {
+					return nil
+				}
+				var status bk.SolveStatus
+				if err := json.Unmarshal(ev.Status, &status); err != nil {
+					return fmt.Errorf("decode status event: %w", err)
+				}
+				events <- &status
+				if ev.Done {
+					if ev.Err != "" {
+						return errors.New(ev.Err)
+					}
+					return nil
+				}
+			}
+		}
+	})
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	outJSON, err := c.agent.FetchOutput(jobID)
+	if err != nil {
+		return nil, fmt.Errorf("agent fetch output: %w", err)
+	}
+	return compiler.Compile("agent-output.json", bytes.NewReader(outJSON))
+}
+
 func (c *Client) buildfn(ctx context.Context, env *Env, ch chan *bk.SolveStatus, w io.WriteCloser) error {
 	lg := log.Ctx(ctx)
 
@@ -100,18 +240,41 @@ func (c *Client) buildfn(ctx context.Context, env *Env, ch chan *bk.SolveStatus,
 		localdirs[label] = abs
 	}
 
+	cacheImports, err := cacheImportOpts(c.opts.CacheImportRefs)
+	if err != nil {
+		return fmt.Errorf("cache import: %w", err)
+	}
+	cacheExports, err := cacheExportOpts(c.opts)
+	if err != nil {
+		return fmt.Errorf("cache export: %w", err)
+	}
+	extraExports, err := exportEntries(c.opts.Outputs)
+	if err != nil {
+		return fmt.Errorf("outputs: %w", err)
+	}
+	session, err := sessionAttachables(c.opts)
+	if err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+
 	// Setup solve options
 	opts := bk.SolveOpt{
 		LocalDirs: localdirs,
-		// FIXME: catch output & return as cue value
-		Exports: []bk.ExportEntry{
+		Session:   session,
+		// FIXME: catch output & return as cue value.
+		// The cue-output tar exporter always runs; extraExports are
+		// whatever additional artifacts the caller asked for (images,
+		// local dirs, ...) via ClientOpts.Outputs.
+		Exports: append([]bk.ExportEntry{
 			{
 				Type: bk.ExporterTar,
 				Output: func(m map[string]string) (io.WriteCloser, error) {
 					return w, nil
 				},
 			},
-		},
+		}, extraExports...),
+		CacheImports: cacheImports,
+		CacheExports: cacheExports,
 	}
 
 	// Call buildkit solver
@@ -120,8 +283,9 @@ func (c *Client) buildfn(ctx context.Context, env *Env, ch chan *bk.SolveStatus,
 		Interface("attrs", opts.FrontendAttrs).
 		Msg("spawning buildkit job")
 
+	network := c.opts.Network
 	resp, err := c.c.Build(ctx, opts, "", func(ctx context.Context, c bkgw.Client) (*bkgw.Result, error) {
-		s := NewSolver(c)
+		s := NewSolver(c, network)
 
 		if err := env.Update(ctx, s); err != nil {
 			return nil, err
@@ -192,69 +356,3 @@ func (c *Client) outputfn(ctx context.Context, r io.Reader) (*compiler.Value, er
 	}
 	return out, nil
 }
-
-func (c *Client) logSolveStatus(ctx context.Context, ch chan *bk.SolveStatus) error {
-	parseName := func(v *bk.Vertex) (string, string) {
-		// Pattern: `@name@ message`. Minimal length is len("@X@ ")
-		if len(v.Name) < 2 || !strings.HasPrefix(v.Name, "@") {
-			return "", v.Name
-		}
-
-		prefixEndPos := strings.Index(v.Name[1:], "@")
-		if prefixEndPos == -1 {
-			return "", v.Name
-		}
-
-		component := v.Name[1 : prefixEndPos+1]
-		return component, v.Name[prefixEndPos+3 : len(v.Name)]
-	}
-
-	return progressui.PrintSolveStatus(ctx, ch,
-		func(v *bk.Vertex, index int) {
-			component, name := parseName(v)
-			lg := log.
-				Ctx(ctx).
-				With().
-				Str("component", component).
-				Logger()
-
-			lg.
-				Debug().
-				Msg(fmt.Sprintf("#%d %s\n", index, name))
-			lg.
-				Debug().
-				Msg(fmt.Sprintf("#%d %s\n", index, v.Digest))
-		},
-		func(v *bk.Vertex, format string, a ...interface{}) {
-			component, _ := parseName(v)
-			lg := log.
-				Ctx(ctx).
-				With().
-				Str("component", component).
-				Logger()
-
-			lg.
-				Debug().
-				Msg(fmt.Sprintf(format, a...))
-		},
-		func(v *bk.Vertex, stream int, partial bool, format string, a ...interface{}) {
-			component, _ := parseName(v)
-			lg := log.
-				Ctx(ctx).
-				With().
-				Str("component", component).
-				Logger()
-
-			switch stream {
-			case 1:
-				lg.
-					Info().
-					Msg(fmt.Sprintf(format, a...))
-			case 2:
-				lg.
-					Error().
-					Msg(fmt.Sprintf(format, a...))
-			}
-		},
-	)
-}
@@ -0,0 +1,29 @@
+// Package containerd registers a "containerd://" buildkit connhelper for
+// dialing a buildkitd that's running as a containerd task rather than a
+// docker container, eg. "containerd:///run/containerd/containerd-debug.sock"
+// mirrors the "docker-container://" helper but execs nothing: buildkitd is
+// assumed to already be reachable over the given unix socket path.
+package containerd
+
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/moby/buildkit/client/connhelper"
+)
+
+func init() {
+	connhelper.Register("containerd", Helper)
+}
+
+// Helper builds a ConnectionHelper that dials the unix socket named by the
+// URL's path, eg. "containerd:///run/buildkit/buildkitd.sock".
+func Helper(u *url.URL) (*connhelper.ConnHelper, error) {
+	return &connhelper.ConnHelper{
+		ContextDialer: func(ctx context.Context, addr string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", u.Path)
+		},
+	}, nil
+}
@@ -0,0 +1,20 @@
+package dagger
+
+import "encoding/json"
+
+// DecodeEnv reconstructs an Env from the plan JSON sent by a dagger client
+// to a `dagger agent` over agentrpc. It round-trips through Go's encoding/
+// json rather than the cue compiler, so it only covers the subset of Env
+// state that survives plain JSON (state, plan and context values do not
+// yet).
+//
+// FIXME: this loses whatever of Env's state isn't exported via
+// encoding/json; agent-backed Compute should carry a compiler.Value over
+// the wire instead once Env exposes one.
+func DecodeEnv(planJSON []byte) (*Env, error) {
+	env := &Env{}
+	if err := json.Unmarshal(planJSON, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
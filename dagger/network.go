@@ -0,0 +1,25 @@
+package dagger
+
+// NetworkConfig describes how the host's network identity should be
+// reflected inside every container the solver executes, so that cue
+// configs see the same DNS behaviour as the host they're built on
+// (important on corporate networks and split-horizon DNS setups that
+// buildkit's own default resolv.conf doesn't know about).
+type NetworkConfig struct {
+	// DNS are nameserver IPs to use in place of the container's default
+	// resolv.conf.
+	DNS []string
+	// DNSSearch are search domains appended to resolv.conf.
+	DNSSearch []string
+	// DNSOptions are raw resolv.conf "options" lines (eg. "ndots:2").
+	DNSOptions []string
+	// ExtraHosts are additional "host:ip" entries appended to every
+	// container's /etc/hosts, same syntax as `docker run --add-host`.
+	ExtraHosts []string
+}
+
+// Empty reports whether nc carries no overrides, in which case the
+// solver should leave buildkit's default DNS/hosts handling alone.
+func (nc NetworkConfig) Empty() bool {
+	return len(nc.DNS) == 0 && len(nc.DNSSearch) == 0 && len(nc.DNSOptions) == 0 && len(nc.ExtraHosts) == 0
+}
@@ -0,0 +1,25 @@
+package dagger
+
+import "testing"
+
+func TestNetworkConfigEmpty(t *testing.T) {
+	cases := []struct {
+		name string
+		nc   NetworkConfig
+		want bool
+	}{
+		{name: "zero value", nc: NetworkConfig{}, want: true},
+		{name: "dns set", nc: NetworkConfig{DNS: []string{"8.8.8.8"}}, want: false},
+		{name: "search set", nc: NetworkConfig{DNSSearch: []string{"example.com"}}, want: false},
+		{name: "options set", nc: NetworkConfig{DNSOptions: []string{"ndots:2"}}, want: false},
+		{name: "extra hosts set", nc: NetworkConfig{ExtraHosts: []string{"foo:127.0.0.1"}}, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.nc.Empty(); got != c.want {
+				t.Errorf("Empty() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package dagger
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+// OutputType selects which buildkit exporter a named Output is routed to.
+type OutputType string
+
+const (
+	// OutputOCI writes an OCI image tarball to Dest.
+	OutputOCI OutputType = "oci"
+	// OutputDocker writes a docker image tarball to Dest.
+	OutputDocker OutputType = "docker"
+	// OutputImage pushes an image to a registry ref given by Dest, using
+	// credentials from the client's session auth provider.
+	OutputImage OutputType = "image"
+	// OutputLocal writes a filesystem tree to the host directory Dest.
+	OutputLocal OutputType = "local"
+	// OutputCacheOnly solves the artifact for its cache side effects
+	// without exporting anything.
+	OutputCacheOnly OutputType = "cacheonly"
+)
+
+// Output is one named artifact a cue config can request be exported
+// alongside the default cue-output tar stream, configured on the client
+// via ClientOpts.Outputs. Dest is an image ref for OutputImage, or a host path for
+// OutputOCI/OutputDocker/OutputLocal; it is unused for OutputCacheOnly.
+type Output struct {
+	Name  string
+	Type  OutputType
+	Dest  string
+	Attrs map[string]string
+}
+
+// exportEntry converts an Output into the bk.ExportEntry buildkit expects,
+// merging in any caller-supplied Attrs (eg. "oci-mediatypes" for
+// OutputOCI). For OutputImage, "name" and "push" are always set from Dest,
+// overriding any caller-supplied values.
+func (o Output) exportEntry() (bk.ExportEntry, error) {
+	attrs := map[string]string{}
+	for k, v := range o.Attrs {
+		attrs[k] = v
+	}
+
+	switch o.Type {
+	case OutputOCI:
+		return bk.ExportEntry{Type: bk.ExporterOCI, Output: tarFileOutput(o.Dest), Attrs: attrs}, nil
+	case OutputDocker:
+		return bk.ExportEntry{Type: bk.ExporterDocker, Output: tarFileOutput(o.Dest), Attrs: attrs}, nil
+	case OutputImage:
+		attrs["name"] = o.Dest
+		attrs["push"] = "true"
+		return bk.ExportEntry{Type: bk.ExporterImage, Attrs: attrs}, nil
+	case OutputLocal:
+		return bk.ExportEntry{Type: bk.ExporterLocal, OutputDir: o.Dest, Attrs: attrs}, nil
+	case OutputCacheOnly:
+		return bk.ExportEntry{Type: string(OutputCacheOnly), Attrs: attrs}, nil
+	default:
+		return bk.ExportEntry{}, fmt.Errorf("output %q: unknown type %q", o.Name, o.Type)
+	}
+}
+
+// tarFileOutput opens dest for writing and returns it as an
+// bk.ExportEntry.Output callback, the way the OCI and Docker exporters
+// expect to receive their single tarball (same mechanism the internal
+// cue-output tar exporter uses in buildfn).
+func tarFileOutput(dest string) func(map[string]string) (io.WriteCloser, error) {
+	return func(map[string]string) (io.WriteCloser, error) {
+		f, err := os.Create(dest)
+		if err != nil {
+			return nil, fmt.Errorf("create %q: %w", dest, err)
+		}
+		return f, nil
+	}
+}
+
+// exportEntries converts every Output in outputs into buildkit export
+// entries, to be appended to the always-present cue-output tar exporter.
+func exportEntries(outputs []Output) ([]bk.ExportEntry, error) {
+	entries := make([]bk.ExportEntry, 0, len(outputs))
+	for _, o := range outputs {
+		entry, err := o.exportEntry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+package dagger
+
+import (
+	"path/filepath"
+	"testing"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+func TestOutputExportEntry(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		name     string
+		out      Output
+		wantType string
+		wantErr  bool
+	}{
+		{
+			name:     "oci",
+			out:      Output{Name: "img", Type: OutputOCI, Dest: filepath.Join(dir, "oci.tar")},
+			wantType: bk.ExporterOCI,
+		},
+		{
+			name:     "docker",
+			out:      Output{Name: "img", Type: OutputDocker, Dest: filepath.Join(dir, "docker.tar")},
+			wantType: bk.ExporterDocker,
+		},
+		{
+			name:     "image",
+			out:      Output{Name: "img", Type: OutputImage, Dest: "registry.example.com/foo:latest"},
+			wantType: bk.ExporterImage,
+		},
+		{
+			name:     "local",
+			out:      Output{Name: "dir", Type: OutputLocal, Dest: dir},
+			wantType: bk.ExporterLocal,
+		},
+		{
+			name:     "cacheonly",
+			out:      Output{Name: "cache", Type: OutputCacheOnly},
+			wantType: "cacheonly",
+		},
+		{
+			name:    "unknown type",
+			out:     Output{Name: "bogus", Type: "bogus"},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entry, err := c.out.exportEntry()
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if entry.Type != c.wantType {
+				t.Errorf("Type = %q, want %q", entry.Type, c.wantType)
+			}
+			if entry.OutputDir != "" && c.out.Type != OutputLocal {
+				t.Errorf("OutputDir should only be set for OutputLocal, got %q for %s", entry.OutputDir, c.out.Type)
+			}
+		})
+	}
+}
+
+func TestOutputExportEntryImageAttrs(t *testing.T) {
+	entry, err := Output{Name: "img", Type: OutputImage, Dest: "registry.example.com/foo:latest"}.exportEntry()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Attrs["name"] != "registry.example.com/foo:latest" {
+		t.Errorf("Attrs[name] = %q, want image ref", entry.Attrs["name"])
+	}
+	if entry.Attrs["push"] != "true" {
+		t.Errorf("Attrs[push] = %q, want \"true\"", entry.Attrs["push"])
+	}
+}
@@ -0,0 +1,27 @@
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+// JSONL writes one JSON object per line per SolveStatus event, suitable
+// for a CI system to ingest (eg. to render its own progress UI, or to
+// archive a build log) without scraping formatted text.
+type JSONL struct {
+	Out io.Writer
+}
+
+func (j JSONL) Write(ctx context.Context, ch <-chan *bk.SolveStatus) error {
+	enc := json.NewEncoder(j.Out)
+	for status := range ch {
+		if err := enc.Encode(status); err != nil {
+			return fmt.Errorf("progress: encode status: %w", err)
+		}
+	}
+	return nil
+}
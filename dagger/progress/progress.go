@@ -0,0 +1,87 @@
+// Package progress provides structured consumers of a buildkit solve
+// status stream, replacing the single hardcoded zerolog printer that used
+// to live in dagger.Client.logSolveStatus. A Compute call can attach any
+// number of Writers (TTY, zerolog, JSON-lines, an in-memory recorder, ...)
+// and every one sees every event, instead of only ever getting scraped
+// log lines.
+package progress
+
+import (
+	"context"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+// Writer consumes a buildkit solve status stream until ch is closed (or
+// ctx is cancelled), and reports the first error it hits, if any.
+type Writer interface {
+	Write(ctx context.Context, ch <-chan *bk.SolveStatus) error
+}
+
+// fanoutSlot is one attached writer's private channel, plus a way for the
+// fanout loop to notice it has stopped reading (because Write returned,
+// successfully or not) so a slow or dead writer can't wedge the others.
+type fanoutSlot struct {
+	ch   chan *bk.SolveStatus
+	done chan struct{}
+}
+
+// Fanout relays every status event received on in to every attached
+// writer's own channel, then runs each writer concurrently. It blocks
+// until in is closed (or ctx is cancelled) and every writer has returned,
+// and reports the first writer error encountered (after draining the
+// rest). A writer that stops consuming early (error, closed output, ...)
+// is simply skipped for the rest of the run instead of blocking the
+// delivery of events to every other writer.
+func Fanout(ctx context.Context, in <-chan *bk.SolveStatus, writers ...Writer) error {
+	slots := make([]fanoutSlot, len(writers))
+	errs := make(chan error, len(writers))
+	for i, w := range writers {
+		slots[i] = fanoutSlot{
+			ch:   make(chan *bk.SolveStatus),
+			done: make(chan struct{}),
+		}
+		i, w := i, w
+		go func() {
+			defer close(slots[i].done)
+			errs <- w.Write(ctx, slots[i].ch)
+		}()
+	}
+
+loop:
+	for {
+		select {
+		case ev, ok := <-in:
+			if !ok {
+				break loop
+			}
+			for _, s := range slots {
+				select {
+				case s.ch <- ev:
+				case <-s.done:
+					// writer already stopped consuming; drop the event
+					// for it instead of blocking the other writers.
+				case <-ctx.Done():
+					break loop
+				}
+			}
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	for _, s := range slots {
+		close(s.ch)
+	}
+
+	var firstErr error
+	for range writers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
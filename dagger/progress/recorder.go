@@ -0,0 +1,50 @@
+package progress
+
+import (
+	"context"
+	"sync"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+// Recorder buffers every SolveStatus event in memory so a Compute call's
+// progress can be replayed after the fact (eg. by an IDE plugin that
+// wasn't attached when the build started).
+type Recorder struct {
+	mu     sync.Mutex
+	events []*bk.SolveStatus
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Write(ctx context.Context, ch <-chan *bk.SolveStatus) error {
+	for status := range ch {
+		r.mu.Lock()
+		r.events = append(r.events, status)
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// Events returns every event recorded so far. The returned slice is a
+// snapshot; events recorded after the call won't appear in it.
+func (r *Recorder) Events() []*bk.SolveStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]*bk.SolveStatus, len(r.events))
+	copy(out, r.events)
+	return out
+}
+
+// Replay sends every recorded event to w, blocking until w.Write returns.
+func (r *Recorder) Replay(ctx context.Context, w Writer) error {
+	events := r.Events()
+	ch := make(chan *bk.SolveStatus, len(events))
+	for _, ev := range events {
+		ch <- ev
+	}
+	close(ch)
+	return w.Write(ctx, ch)
+}
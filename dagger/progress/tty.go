@@ -0,0 +1,20 @@
+package progress
+
+import (
+	"context"
+	"io"
+
+	bk "github.com/moby/buildkit/client"
+
+	"dagger.io/go/pkg/progressui"
+)
+
+// TTY renders the solve status stream as a live-updating terminal display,
+// the same rendering buildkit's own CLI tools use.
+type TTY struct {
+	Out io.Writer
+}
+
+func (t TTY) Write(ctx context.Context, ch <-chan *bk.SolveStatus) error {
+	return progressui.DisplaySolveStatus(ctx, t.Out, ch)
+}
@@ -0,0 +1,66 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	bk "github.com/moby/buildkit/client"
+	"github.com/rs/zerolog/log"
+)
+
+// Zerolog is the original dagger progress writer: it parses the
+// "@component@ message" vertex name convention and logs everything at
+// Debug level (stdout/stderr of a step land at Info/Error).
+type Zerolog struct{}
+
+func (Zerolog) Write(ctx context.Context, ch <-chan *bk.SolveStatus) error {
+	vertices := map[string]*bk.Vertex{}
+
+	for status := range ch {
+		for _, v := range status.Vertexes {
+			vertices[v.Digest.String()] = v
+			component, name := parseName(v)
+			lg := log.Ctx(ctx).With().Str("component", component).Logger()
+			lg.Debug().Msg(fmt.Sprintf("%s", name))
+			lg.Debug().Msg(fmt.Sprintf("%s", v.Digest))
+		}
+		for _, l := range status.Logs {
+			v := vertices[l.Vertex.String()]
+			var component string
+			if v != nil {
+				component, _ = parseName(v)
+			}
+			lg := log.Ctx(ctx).With().Str("component", component).Logger()
+			switch l.Stream {
+			case 1:
+				lg.Info().Msg(string(l.Data))
+			case 2:
+				lg.Error().Msg(string(l.Data))
+			}
+		}
+	}
+	return nil
+}
+
+// parseName splits a vertex name following dagger's "@component@ message"
+// convention into (component, message). A vertex name without that prefix
+// is returned unchanged, with an empty component.
+func parseName(v *bk.Vertex) (string, string) {
+	// Pattern: `@name@ message`. Minimal length is len("@X@ ")
+	if len(v.Name) < 2 || !strings.HasPrefix(v.Name, "@") {
+		return "", v.Name
+	}
+
+	prefixEndPos := strings.Index(v.Name[1:], "@")
+	if prefixEndPos == -1 {
+		return "", v.Name
+	}
+
+	if prefixEndPos+3 > len(v.Name) {
+		return "", v.Name
+	}
+
+	component := v.Name[1 : prefixEndPos+1]
+	return component, v.Name[prefixEndPos+3:]
+}
@@ -0,0 +1,59 @@
+package progress
+
+import (
+	"testing"
+
+	bk "github.com/moby/buildkit/client"
+)
+
+func TestParseName(t *testing.T) {
+	cases := []struct {
+		name          string
+		in            string
+		wantComponent string
+		wantMessage   string
+	}{
+		{
+			name:          "tagged vertex",
+			in:            "@build@ compiling",
+			wantComponent: "build",
+			wantMessage:   "compiling",
+		},
+		{
+			name:          "no prefix",
+			in:            "plain message",
+			wantComponent: "",
+			wantMessage:   "plain message",
+		},
+		{
+			name:          "unterminated prefix",
+			in:            "@build compiling",
+			wantComponent: "",
+			wantMessage:   "@build compiling",
+		},
+		{
+			name:          "too short to be tagged",
+			in:            "@",
+			wantComponent: "",
+			wantMessage:   "@",
+		},
+		{
+			name:          "empty component",
+			in:            "@@ compiling",
+			wantComponent: "",
+			wantMessage:   "compiling",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			component, message := parseName(&bk.Vertex{Name: c.in})
+			if component != c.wantComponent {
+				t.Errorf("component = %q, want %q", component, c.wantComponent)
+			}
+			if message != c.wantMessage {
+				t.Errorf("message = %q, want %q", message, c.wantMessage)
+			}
+		})
+	}
+}
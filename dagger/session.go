@@ -0,0 +1,119 @@
+// Secrets, SSH sockets and registry auth are forwarded to buildkit over
+// the session attachables built here. The cue-facing #Secret, #SSHSocket
+// and #RegistryAuth stdlib ops that lower to llb.AddSecret/AddSSHSocket/
+// registry credential lookups live in the solver (NewSolver), referencing
+// the same SecretSource.ID / SSHSocket.ID values.
+package dagger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/auth/authprovider"
+	"github.com/moby/buildkit/session/secrets"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+)
+
+// SecretSource describes where a cue-visible secret's value comes from.
+// Exactly one of Env, FilePath or Command should be set; ID is the name
+// cue configs reference it by via #Secret.
+type SecretSource struct {
+	ID string
+
+	Env      string
+	FilePath string
+	Command  []string
+}
+
+// SSHSocket describes an SSH agent socket (or a list of private key
+// files) made available to cue configs via #SSHSocket. An empty Paths
+// uses $SSH_AUTH_SOCK.
+type SSHSocket struct {
+	ID    string
+	Paths []string
+}
+
+// rawSecretStore serves secrets resolved once up front (eg. by running a
+// Command) straight out of memory, instead of round-tripping them through
+// a file or the process environment where any other forked subprocess
+// could read them.
+type rawSecretStore map[string][]byte
+
+func (s rawSecretStore) GetSecret(ctx context.Context, id string) ([]byte, error) {
+	v, ok := s[id]
+	if !ok {
+		return nil, secrets.ErrNotFound
+	}
+	return v, nil
+}
+
+// layeredSecretStore checks raw (in-memory) secrets before falling back
+// to store (buildkit's own file/env-backed secretsprovider.Store).
+type layeredSecretStore struct {
+	raw   rawSecretStore
+	store secrets.SecretStore
+}
+
+func (s layeredSecretStore) GetSecret(ctx context.Context, id string) ([]byte, error) {
+	if v, err := s.raw.GetSecret(ctx, id); err == nil {
+		return v, nil
+	}
+	return s.store.GetSecret(ctx, id)
+}
+
+// sessionAttachables builds the buildkit session attachables for the
+// secrets, SSH sockets, and docker registry auth configured on opts, for
+// use as bk.SolveOpt.Session. Registry auth is always attached so that
+// #RegistryAuth lookups (and plain image pulls) can use the user's
+// ~/.docker/config.json.
+func sessionAttachables(opts ClientOpts) ([]session.Attachable, error) {
+	attachables := []session.Attachable{
+		authprovider.NewDockerAuthProvider(os.Stderr),
+	}
+
+	if len(opts.Secrets) > 0 {
+		raw := rawSecretStore{}
+		var sources []secretsprovider.Source
+		for _, s := range opts.Secrets {
+			switch {
+			case s.FilePath != "":
+				abs, err := filepath.Abs(s.FilePath)
+				if err != nil {
+					return nil, fmt.Errorf("secret %q: %w", s.ID, err)
+				}
+				sources = append(sources, secretsprovider.Source{ID: s.ID, FilePath: abs})
+			case s.Env != "":
+				sources = append(sources, secretsprovider.Source{ID: s.ID, Env: s.Env})
+			case len(s.Command) > 0:
+				out, err := exec.Command(s.Command[0], s.Command[1:]...).Output()
+				if err != nil {
+					return nil, fmt.Errorf("secret %q: run command: %w", s.ID, err)
+				}
+				raw[s.ID] = out
+			default:
+				return nil, fmt.Errorf("secret %q: no source set (Env, FilePath or Command)", s.ID)
+			}
+		}
+		store, err := secretsprovider.NewStore(sources)
+		if err != nil {
+			return nil, fmt.Errorf("secrets provider: %w", err)
+		}
+		attachables = append(attachables, secretsprovider.NewSecretProvider(layeredSecretStore{raw: raw, store: store}))
+	}
+
+	for _, sock := range opts.SSHSockets {
+		cfg := sshprovider.AgentConfig{ID: sock.ID, Paths: sock.Paths}
+		provider, err := sshprovider.NewSSHAgentProvider([]sshprovider.AgentConfig{cfg})
+		if err != nil {
+			return nil, fmt.Errorf("ssh socket %q: %w", sock.ID, err)
+		}
+		attachables = append(attachables, provider)
+	}
+
+	return attachables, nil
+}